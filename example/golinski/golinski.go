@@ -23,23 +23,26 @@ func main() {
 		{5.0, 5.5},
 	}
 
+	// Each constraint is of the form g(x) <= 1; returning g(x)-1 directly, rather than collapsing
+	// it through swarm.BoolConstraint, lets infeasible particles rank by how badly they violate
+	// instead of just how many constraints they trip.
 	constraints := []swarm.Constraint{
-		func(x []float64) bool { return true },
-		func(x []float64) bool { return 27.0/(x[0]*math.Pow(x[1], 2)*x[2]) <= 1 },
-		func(x []float64) bool { return 397.5/(x[0]*math.Pow(x[1], 2)*math.Pow(x[2], 2)) <= 1 },
-		func(x []float64) bool { return 1.93*math.Pow(x[3], 3)/(x[1]*x[2]*math.Pow(x[5], 4)) <= 1 },
-		func(x []float64) bool { return 1.93*math.Pow(x[4], 3)/(x[1]*x[2]*math.Pow(x[6], 4)) <= 1 },
-		func(x []float64) bool {
-			return math.Sqrt(math.Pow(745*x[3]/x[1]/x[2], 2)+16.9*1e6)/(110*math.Pow(x[5], 3)) <= 1
+		func(x []float64) float64 { return -1 },
+		func(x []float64) float64 { return 27.0/(x[0]*math.Pow(x[1], 2)*x[2]) - 1 },
+		func(x []float64) float64 { return 397.5/(x[0]*math.Pow(x[1], 2)*math.Pow(x[2], 2)) - 1 },
+		func(x []float64) float64 { return 1.93*math.Pow(x[3], 3)/(x[1]*x[2]*math.Pow(x[5], 4)) - 1 },
+		func(x []float64) float64 { return 1.93*math.Pow(x[4], 3)/(x[1]*x[2]*math.Pow(x[6], 4)) - 1 },
+		func(x []float64) float64 {
+			return math.Sqrt(math.Pow(745*x[3]/x[1]/x[2], 2)+16.9*1e6)/(110*math.Pow(x[5], 3)) - 1
 		},
-		func(x []float64) bool {
-			return math.Sqrt(math.Pow(745*x[4]/x[1]/x[2], 2)+157.5*1e6)/(85*math.Pow(x[6], 3)) <= 1
+		func(x []float64) float64 {
+			return math.Sqrt(math.Pow(745*x[4]/x[1]/x[2], 2)+157.5*1e6)/(85*math.Pow(x[6], 3)) - 1
 		},
-		func(x []float64) bool { return x[1]*x[2]/40 <= 1 },
-		func(x []float64) bool { return 5*x[1]/x[0] <= 1 },
-		func(x []float64) bool { return x[0]/12/x[1] <= 1 },
-		func(x []float64) bool { return (1.5*x[5]+1.9)/x[3] <= 1 },
-		func(x []float64) bool { return (1.1*x[6]+1.9)/x[4] <= 1 },
+		func(x []float64) float64 { return x[1]*x[2]/40 - 1 },
+		func(x []float64) float64 { return 5*x[1]/x[0] - 1 },
+		func(x []float64) float64 { return x[0]/12/x[1] - 1 },
+		func(x []float64) float64 { return (1.5*x[5]+1.9)/x[3] - 1 },
+		func(x []float64) float64 { return (1.1*x[6]+1.9)/x[4] - 1 },
 	}
 
 	options := swarm.Options{
@@ -54,6 +57,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("could not build swarm: %v", err)
 	}
+	defer pso.Close()
 
 	pso.StepUntil(1e-6)
 
@@ -1,52 +1,31 @@
 package main
 
 import (
-	"bytes"
 	_ "embed"
-	"encoding/csv"
-	"strconv"
+
+	"github.com/quells/pso/pkg/nnet"
 )
 
 //go:embed iris.txt
 var irisRaw []byte
 
-type iris struct {
-	values []float64
-	label  int
+var irisClasses = map[string]int{
+	"setosa":     0,
+	"versicolor": 1,
+	"virginica":  2,
 }
 
 var (
-	trainingData []iris
-	testingData  []iris
+	trainingData nnet.Dataset
+	testingData  nnet.Dataset
 )
 
 func init() {
-	rows, err := csv.NewReader(bytes.NewReader(irisRaw)).ReadAll()
+	dataset, err := nnet.LoadCSV(irisRaw, 4, 4, irisClasses)
 	if err != nil {
 		panic(err)
 	}
 
-	for idx, row := range rows {
-		parsed := iris{
-			values: make([]float64, 4),
-		}
-
-		for i := 0; i < 4; i++ {
-			parsed.values[i], _ = strconv.ParseFloat(row[i], 64)
-		}
-
-		switch row[4] {
-		case "versicolor":
-			parsed.label = 1
-		case "virginica":
-			parsed.label = 2
-		}
-
-		// could be randomized
-		if idx%10 == 0 {
-			testingData = append(testingData, parsed)
-		} else {
-			trainingData = append(trainingData, parsed)
-		}
-	}
+	// could be randomized
+	trainingData, testingData = dataset.Split(10)
 }
@@ -6,32 +6,39 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/quells/pso/pkg/nnet"
 	"github.com/quells/pso/pkg/swarm"
 )
 
 const (
-	numEdges = 55
+	batchSize = 30
 )
 
+var net = nnet.New([]int{4, 4, 4, 3})
+
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
-	shape := make([]swarm.Range, numEdges)
-	for i := 0; i < numEdges; i++ {
+	numWeights := net.NumWeights()
+	shape := make([]swarm.Range, numWeights)
+	for i := 0; i < numWeights; i++ {
 		shape[i][0] = -10.0
 		shape[i][1] = 10.0
 	}
 
+	fitness, beforeStep := net.MiniBatchFitness(trainingData, batchSize)
 	options := swarm.Options{
-		PopulationSize: numEdges * 2,
+		PopulationSize: uint(numWeights * 2),
 		LocalSize:      2,
 		WaitMagnitude:  2.5,
+		BeforeStep:     beforeStep,
 	}
 
-	pso, err := swarm.New(train, shape, options)
+	pso, err := swarm.New(fitness, shape, options)
 	if err != nil {
 		log.Fatalf("could not build swarm: %v", err)
 	}
+	defer pso.Close()
 
 	pso.StepUntil(1e-6)
 
@@ -39,18 +46,10 @@ func main() {
 	// fmt.Println(pso.Best())
 }
 
-func train(weights []float64) (score float64) {
-	for _, flower := range trainingData {
-		predicted := feedForwardNN(weights, flower.values)[flower.label]
-		score -= predicted
-	}
-	return
-}
-
 func test(weights []float64) (score float64) {
 	for _, flower := range trainingData {
-		predicted := argmax(feedForwardNN(weights, flower.values))
-		if predicted == flower.label {
+		predicted := nnet.Argmax(net.Forward(weights, flower.Values))
+		if predicted == flower.Label {
 			score++
 		}
 	}
@@ -0,0 +1,66 @@
+package nnet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/rand"
+	"strconv"
+)
+
+// An Example is one labeled training/testing row: Values is the feature vector and Label is the
+// index of its class.
+type Example struct {
+	Values []float64
+	Label  int
+}
+
+// A Dataset is a collection of labeled Examples.
+type Dataset []Example
+
+// LoadCSV parses raw as CSV, reading the first numFeatures columns of each row as float64
+// features and looking up the class label in the labelCol column against classes.
+func LoadCSV(raw []byte, numFeatures, labelCol int, classes map[string]int) (Dataset, error) {
+	rows, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	dataset := make(Dataset, 0, len(rows))
+	for _, row := range rows {
+		example := Example{Values: make([]float64, numFeatures)}
+		for i := 0; i < numFeatures; i++ {
+			example.Values[i], _ = strconv.ParseFloat(row[i], 64)
+		}
+		example.Label = classes[row[labelCol]]
+		dataset = append(dataset, example)
+	}
+	return dataset, nil
+}
+
+// Split partitions the dataset into train/test sets, putting every nth example (by position,
+// 0-indexed) into the test set and the rest into the train set.
+func (d Dataset) Split(nth int) (train, test Dataset) {
+	for i, example := range d {
+		if nth > 0 && i%nth == 0 {
+			test = append(test, example)
+		} else {
+			train = append(train, example)
+		}
+	}
+	return
+}
+
+// Sample returns a random subset of n Examples drawn without replacement, or the whole dataset
+// (in random order) if n >= len(d).
+func (d Dataset) Sample(n int) Dataset {
+	if n >= len(d) {
+		n = len(d)
+	}
+
+	perm := rand.Perm(len(d))
+	sample := make(Dataset, n)
+	for i := 0; i < n; i++ {
+		sample[i] = d[perm[i]]
+	}
+	return sample
+}
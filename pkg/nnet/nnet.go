@@ -0,0 +1,132 @@
+// Package nnet provides small feed-forward neural network helpers for use as swarm.Fitness
+// functions, promoted out of the hand-rolled MLP that used to live in example/iris.
+package nnet
+
+import (
+	"math"
+
+	"github.com/quells/pso/pkg/swarm"
+)
+
+// A Network is a fully-connected feed-forward network with a tanh activation on every layer
+// (including the output layer) followed by a final softmax, parameterized by a flat weight
+// vector compatible with swarm.Fitness.
+type Network struct {
+	layers []int
+}
+
+// New builds a Network from a layer spec: layers[0] is the number of inputs, layers[len(layers)-1]
+// is the number of output classes, and everything in between is a hidden layer width.
+func New(layers []int) *Network {
+	cp := make([]int, len(layers))
+	copy(cp, layers)
+	return &Network{layers: cp}
+}
+
+// NumWeights returns the number of weights Forward expects, including one bias weight per node.
+func (n *Network) NumWeights() (total int) {
+	for i := 1; i < len(n.layers); i++ {
+		total += (n.layers[i-1] + 1) * n.layers[i]
+	}
+	return
+}
+
+// Forward runs input through the network and returns the softmax output distribution.
+func (n *Network) Forward(weights, input []float64) []float64 {
+	activations := input
+	offset := 0
+	for i := 1; i < len(n.layers); i++ {
+		inSize, outSize := n.layers[i-1], n.layers[i]
+
+		biased := make([]float64, inSize+1)
+		copy(biased, activations)
+		biased[inSize] = 1
+
+		next := make([]float64, outSize)
+		for o := 0; o < outSize; o++ {
+			w := weights[offset : offset+inSize+1]
+			next[o] = math.Tanh(dot(w, biased))
+			offset += inSize + 1
+		}
+		activations = next
+	}
+	return softmax(activations)
+}
+
+// SoftmaxCrossEntropyLoss returns the average cross-entropy loss of the network, with the given
+// weights, over dataset.
+func (n *Network) SoftmaxCrossEntropyLoss(weights []float64, dataset Dataset) float64 {
+	if len(dataset) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, example := range dataset {
+		predicted := n.Forward(weights, example.Values)
+		p := predicted[example.Label]
+		if p <= 0 {
+			p = 1e-12
+		}
+		total -= math.Log(p)
+	}
+	return total / float64(len(dataset))
+}
+
+// Fitness returns a swarm.Fitness that scores weights by their SoftmaxCrossEntropyLoss over the
+// entire dataset.
+func (n *Network) Fitness(dataset Dataset) swarm.Fitness {
+	return func(weights []float64) float64 {
+		return n.SoftmaxCrossEntropyLoss(weights, dataset)
+	}
+}
+
+// MiniBatchFitness returns a swarm.Fitness that scores weights against a random batchSize subset
+// of dataset, along with a step hook that resamples that subset. Wire the step hook to
+// swarm.Options.BeforeStep so the batch is resampled once per Step and shared by every particle,
+// rather than once per particle; without that, particleBest/globalBest comparisons within a Step
+// would be scored against different batches and not be comparable. This trades a noisier fitness
+// signal for skipping the cost of scoring the full dataset on every particle, every step.
+func (n *Network) MiniBatchFitness(dataset Dataset, batchSize int) (fitness swarm.Fitness, beforeStep func()) {
+	batch := dataset.Sample(batchSize)
+
+	beforeStep = func() {
+		batch = dataset.Sample(batchSize)
+	}
+	fitness = func(weights []float64) float64 {
+		return n.SoftmaxCrossEntropyLoss(weights, batch)
+	}
+	return fitness, beforeStep
+}
+
+func dot(u, v []float64) (result float64) {
+	for i, ui := range u {
+		result += ui * v[i]
+	}
+	return
+}
+
+func softmax(input []float64) []float64 {
+	var sum float64
+	e := make([]float64, len(input))
+	for idx, i := range input {
+		ei := math.Exp(i)
+		e[idx] = ei
+		sum += ei
+	}
+	for idx, ei := range e {
+		e[idx] = ei / sum
+	}
+	return e
+}
+
+// Argmax returns the index of the largest value in v.
+func Argmax(v []float64) (idx int) {
+	m := v[0]
+	for i := 1; i < len(v); i++ {
+		if v[i] > m {
+			m = v[i]
+			idx = i
+		}
+	}
+	return
+}
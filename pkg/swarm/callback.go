@@ -0,0 +1,98 @@
+package swarm
+
+// A Callback is invoked after every Step with the step count and a Snapshot of the optimizer's
+// current state. Returning a non-nil error stops the run cleanly.
+type Callback func(step int, snapshot Snapshot) error
+
+// A Snapshot is a read-only view of an optimizer's state taken immediately after a Step.
+type Snapshot struct {
+	Step int
+
+	GlobalBest          []float64
+	GlobalBestFitness   float64
+	GlobalBestViolation float64
+
+	ParticleBest          [][]float64
+	ParticleBestFitness   []float64
+	ParticleBestViolation []float64
+
+	AverageFitness float64
+
+	// Variance is the swarm's per-dimension positional variance this step.
+	Variance []float64
+
+	// Archive holds the current non-dominated Pareto positions. Only populated by MultiOptimizer.
+	Archive [][]float64
+}
+
+// positionVariance computes the per-dimension variance of positions.
+func positionVariance(positions [][]float64, dimensions int) []float64 {
+	variance := make([]float64, dimensions)
+	n := float64(len(positions))
+	if n == 0 {
+		return variance
+	}
+
+	mean := make([]float64, dimensions)
+	for _, pos := range positions {
+		for j, x := range pos {
+			mean[j] += x
+		}
+	}
+	for j := range mean {
+		mean[j] /= n
+	}
+
+	for _, pos := range positions {
+		for j, x := range pos {
+			d := x - mean[j]
+			variance[j] += d * d
+		}
+	}
+	for j := range variance {
+		variance[j] /= n
+	}
+
+	return variance
+}
+
+// copyVector returns a copy of v so a caller holding onto a Snapshot can't observe later
+// in-place mutation of the optimizer's backing slice.
+func copyVector(v []float64) []float64 {
+	return append([]float64(nil), v...)
+}
+
+// copyMatrix deep-copies a [][]float64 so a caller holding onto a Snapshot can't observe later
+// in-place mutation of the optimizer's backing rows.
+func copyMatrix(m [][]float64) [][]float64 {
+	out := make([][]float64, len(m))
+	for i, row := range m {
+		out[i] = copyVector(row)
+	}
+	return out
+}
+
+func (opt *Optimizer) snapshot() Snapshot {
+	return Snapshot{
+		Step: opt.stepCount,
+
+		GlobalBest:          copyVector(opt.globalBestPosition),
+		GlobalBestFitness:   opt.globalBestFitness,
+		GlobalBestViolation: opt.globalBestViolation,
+
+		ParticleBest:          copyMatrix(opt.particleBestPosition),
+		ParticleBestFitness:   copyVector(opt.particleBestFitness),
+		ParticleBestViolation: copyVector(opt.particleBestViolation),
+
+		AverageFitness: opt.averageFitness,
+		Variance:       positionVariance(opt.positions, len(opt.shape)),
+	}
+}
+
+func (opt *MultiOptimizer) snapshot() Snapshot {
+	return Snapshot{
+		Step:     opt.stepCount,
+		Variance: positionVariance(opt.positions, len(opt.shape)),
+		Archive:  copyMatrix(opt.Pareto()),
+	}
+}
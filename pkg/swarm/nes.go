@@ -0,0 +1,152 @@
+package swarm
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// nesExpectedAbsZ is E|Z| for a standard normal Z, used to normalize the sigma update below.
+const nesExpectedAbsZ = 0.7979
+
+// nesDistribution is the per-dimension search distribution a local group uses for its natural
+// evolution strategy blend.
+type nesDistribution struct {
+	mu    []float64
+	sigma []float64
+}
+
+// newNESDistribution seeds a group's distribution from its particles' current positions: mu at
+// the group centroid, sigma at a quarter of each dimension's bounded span, falling back to the
+// group's own spread in that dimension if it is unbounded.
+func newNESDistribution(shape []Range, positions [][]float64, members []int) nesDistribution {
+	dist := nesDistribution{
+		mu:    make([]float64, len(shape)),
+		sigma: make([]float64, len(shape)),
+	}
+
+	for _, idx := range members {
+		for j, x := range positions[idx] {
+			dist.mu[j] += x
+		}
+	}
+	n := float64(len(members))
+	for j := range dist.mu {
+		if n > 0 {
+			dist.mu[j] /= n
+		}
+
+		if span := shape[j][1] - shape[j][0]; span != 0 {
+			dist.sigma[j] = span / 4
+			continue
+		}
+
+		var variance float64
+		for _, idx := range members {
+			d := positions[idx][j] - dist.mu[j]
+			variance += d * d
+		}
+		if n > 0 {
+			variance /= n
+		}
+		dist.sigma[j] = math.Sqrt(variance)
+		if dist.sigma[j] == 0 {
+			dist.sigma[j] = 1
+		}
+	}
+
+	return dist
+}
+
+// nesUtilityWeights computes the utility weight of each rank (1 = best of n) using the standard
+// NES log-utility transform, normalized to sum to one.
+func nesUtilityWeights(n int) []float64 {
+	weights := make([]float64, n)
+	base := math.Log(float64(n)/2 + 1)
+
+	var total float64
+	for rank := 1; rank <= n; rank++ {
+		w := base - math.Log(float64(rank))
+		if w < 0 {
+			w = 0
+		}
+		weights[rank-1] = w
+		total += w
+	}
+	if total > 0 {
+		for i := range weights {
+			weights[i] /= total
+		}
+	}
+	return weights
+}
+
+// groupMembers returns the population indices sharing local group g, using the same fixed-index
+// partition as groupTopology. NES always partitions the population this way, regardless of
+// Options.Topology: mu/sigma adaptation needs a disjoint partition of the whole swarm, which
+// fixed-index groups give for free, but the other Topology implementations (Ring, Von Neumann,
+// Random, ...) describe overlapping per-particle neighborhoods rather than a partition, so there
+// is no general way to derive NES groups from an arbitrary Topology.
+func (opt *Optimizer) groupMembers(g int) []int {
+	if opt.nesGroups == nil {
+		opt.nesGroups = &groupTopology{groupCount: opt.options.groupCount}
+	}
+	return opt.nesGroups.Neighbors(g, int(opt.options.PopulationSize))
+}
+
+// updateNES runs one generation of the natural evolution strategy hybrid step: each local group's
+// mu/sigma is adapted from the current ranking of its particles, and an Options.NESBlend fraction
+// of the group's weakest particles are resampled from Normal(mu, sigma) instead of receiving the
+// usual PSO velocity update, recovering step size when the swarm has collapsed.
+func (opt *Optimizer) updateNES() {
+	groupCount := opt.options.groupCount
+
+	if opt.nesState == nil {
+		opt.nesState = make([]nesDistribution, groupCount)
+		for g := 0; g < groupCount; g++ {
+			opt.nesState[g] = newNESDistribution(opt.shape, opt.positions, opt.groupMembers(g))
+		}
+	}
+	opt.resampled = make([]bool, opt.options.PopulationSize)
+
+	for g := 0; g < groupCount; g++ {
+		members := opt.groupMembers(g)
+		sort.Slice(members, func(a, b int) bool {
+			return better(
+				opt.lastFitness[members[a]], opt.lastViolation[members[a]],
+				opt.lastFitness[members[b]], opt.lastViolation[members[b]],
+			)
+		})
+
+		weights := nesUtilityWeights(len(members))
+		dist := &opt.nesState[g]
+
+		dMu := make([]float64, len(opt.shape))
+		dLogSigma := make([]float64, len(opt.shape))
+		for rank, idx := range members {
+			w := weights[rank]
+			for j, x := range opt.positions[idx] {
+				dMu[j] += w * (x - dist.mu[j])
+				z := (x - dist.mu[j]) / dist.sigma[j]
+				dLogSigma[j] += w * (math.Abs(z)/nesExpectedAbsZ - 1)
+			}
+		}
+		for j := range dist.mu {
+			dist.mu[j] += opt.options.LRMu * dMu[j]
+			dist.sigma[j] *= math.Exp(opt.options.LRSigma * dLogSigma[j])
+		}
+
+		resampleCount := int(opt.options.NESBlend * float64(len(members)))
+		for i := 0; i < resampleCount; i++ {
+			idx := members[len(members)-1-i] // resample the group's weakest particles first
+			for j := range opt.positions[idx] {
+				opt.positions[idx][j] = dist.mu[j] + dist.sigma[j]*rand.NormFloat64()
+				opt.velocities[idx][j] = 0
+			}
+			for j, bounds := range opt.options.Bounds {
+				opt.positions[idx][j] = bounds.Clip(opt.positions[idx][j])
+			}
+			opt.resampled[idx] = true
+		}
+	}
+}
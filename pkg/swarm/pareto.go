@@ -0,0 +1,389 @@
+package swarm
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// A MultiFitness function scores a candidate particle position against several objectives at
+// once. As with Fitness, lower values are better for every objective; negate an objective to
+// maximize it instead.
+type MultiFitness func([]float64) []float64
+
+// archiveEntry is a single non-dominated solution retained in a MultiOptimizer's Pareto archive.
+type archiveEntry struct {
+	position   []float64
+	objectives []float64
+}
+
+// dominates reports whether a is at least as good as b in every objective and strictly better in
+// at least one, i.e. whether a Pareto-dominates b.
+func dominates(a, b []float64) bool {
+	betterInAny := false
+	for i, ai := range a {
+		if ai > b[i] {
+			return false
+		}
+		if ai < b[i] {
+			betterInAny = true
+		}
+	}
+	return betterInAny
+}
+
+// MultiOptimizer runs particle swarm optimization against a MultiFitness. Rather than converging
+// on a single globalBestFitness, it maintains an external archive of non-dominated solutions and
+// steers particles toward under-explored regions of the Pareto front.
+type MultiOptimizer struct {
+	fitness MultiFitness
+	shape   []Range
+	options Options
+
+	positions  [][]float64 // [populationSize]position
+	velocities [][]float64 // [populationSize]velocity
+	stallCount []uint
+
+	particleBestPosition   [][]float64 // [populationSize]position
+	particleBestObjectives [][]float64 // [populationSize]objectives
+
+	archive []archiveEntry
+
+	stepCount   int
+	callbackErr error
+}
+
+// Pareto returns the positions of the particles currently held in the non-dominated archive.
+func (opt *MultiOptimizer) Pareto() [][]float64 {
+	if opt == nil {
+		return nil
+	}
+
+	result := make([][]float64, len(opt.archive))
+	for i, entry := range opt.archive {
+		result[i] = entry.position
+	}
+	return result
+}
+
+// ParetoObjectives returns the objective vectors corresponding 1:1 with Pareto's positions.
+func (opt *MultiOptimizer) ParetoObjectives() [][]float64 {
+	if opt == nil {
+		return nil
+	}
+
+	result := make([][]float64, len(opt.archive))
+	for i, entry := range opt.archive {
+		result[i] = entry.objectives
+	}
+	return result
+}
+
+// A NewMulti particle swarm optimizer.
+//
+// The shape is only used to initialize particle positions and velocities.
+// It does not impose Constraints or Bounds.
+func NewMulti(fitness MultiFitness, shape []Range, options Options) (opt *MultiOptimizer, err error) {
+	if len(shape) == 0 {
+		err = ErrInvalidShape
+		return
+	}
+
+	opt = &MultiOptimizer{
+		fitness: fitness,
+		shape:   shape,
+	}
+
+	options = withOptionDefaults(options, len(shape))
+	opt.options = options
+
+	opt.reset()
+	return
+}
+
+func (opt *MultiOptimizer) reset() {
+	opt.positions, opt.velocities = initPositions(opt.shape, opt.options.PopulationSize)
+	opt.stallCount = make([]uint, opt.options.PopulationSize)
+
+	opt.particleBestPosition = make([][]float64, opt.options.PopulationSize)
+	opt.particleBestObjectives = make([][]float64, opt.options.PopulationSize)
+	for i := range opt.particleBestPosition {
+		opt.particleBestPosition[i] = make([]float64, len(opt.shape))
+		copy(opt.particleBestPosition[i], opt.positions[i])
+	}
+
+	opt.archive = nil
+
+	opt.stepCount = 0
+	opt.callbackErr = nil
+}
+
+type multiParticleFitness struct {
+	idx        int
+	objectives []float64
+}
+
+func (opt *MultiOptimizer) getParticleObjectives(idx int) (result multiParticleFitness) {
+	result.idx = idx
+
+	position := opt.positions[idx]
+	for i, bounds := range opt.options.Bounds {
+		if !bounds.Contains(position[i]) {
+			return
+		}
+	}
+
+	result.objectives = opt.fitness(position)
+	return
+}
+
+// updateArchive inserts position/objectives into the archive if it is not dominated by any
+// current member, removing any members it in turn dominates.
+func (opt *MultiOptimizer) updateArchive(position, objectives []float64) {
+	kept := opt.archive[:0]
+	for _, entry := range opt.archive {
+		if dominates(entry.objectives, objectives) {
+			return
+		}
+		if !dominates(objectives, entry.objectives) {
+			kept = append(kept, entry)
+		}
+	}
+
+	pos := make([]float64, len(position))
+	copy(pos, position)
+	obj := make([]float64, len(objectives))
+	copy(obj, objectives)
+
+	opt.archive = append(kept, archiveEntry{position: pos, objectives: obj})
+}
+
+// truncateArchive drops the most crowded members (smallest crowding distance) until the archive
+// fits within Options.ArchiveSize, NSGA-II style, so a continuous front can't grow the archive
+// without bound.
+func (opt *MultiOptimizer) truncateArchive() {
+	limit := int(opt.options.ArchiveSize)
+	if limit <= 0 || len(opt.archive) <= limit {
+		return
+	}
+
+	distances := crowdingDistances(opt.archive)
+	order := make([]int, len(opt.archive))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return distances[order[a]] > distances[order[b]]
+	})
+
+	kept := make([]archiveEntry, limit)
+	for i := 0; i < limit; i++ {
+		kept[i] = opt.archive[order[i]]
+	}
+	opt.archive = kept
+}
+
+func (opt *MultiOptimizer) updateFitness() {
+	var wg sync.WaitGroup
+	todoIdx := make(chan int, int(opt.options.Parallelism))
+	results := make(chan multiParticleFitness, int(opt.options.Parallelism))
+	for i := 0; i < int(opt.options.Parallelism); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				idx, ok := <-todoIdx
+				if !ok {
+					return
+				}
+
+				results <- opt.getParticleObjectives(idx)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for idx := 0; idx < int(opt.options.PopulationSize); idx++ {
+			todoIdx <- idx
+		}
+	}()
+
+	for i := 0; i < int(opt.options.PopulationSize); i++ {
+		result := <-results
+		if result.objectives == nil {
+			opt.stallCount[result.idx]++
+			continue
+		}
+
+		if opt.particleBestObjectives[result.idx] == nil ||
+			!dominates(opt.particleBestObjectives[result.idx], result.objectives) {
+			opt.particleBestObjectives[result.idx] = result.objectives
+			copy(opt.particleBestPosition[result.idx], opt.positions[result.idx])
+		}
+
+		opt.updateArchive(opt.positions[result.idx], result.objectives)
+	}
+	close(todoIdx)
+	wg.Wait()
+
+	opt.truncateArchive()
+}
+
+// crowdingDistances assigns each archive member a measure of how isolated it is along the Pareto
+// front: boundary members (extreme in some objective) get math.Inf(1), interior members get the
+// sum of their normalized neighbor gaps across all objectives.
+func crowdingDistances(archive []archiveEntry) []float64 {
+	n := len(archive)
+	dist := make([]float64, n)
+	if n == 0 {
+		return dist
+	}
+	if n <= 2 {
+		for i := range dist {
+			dist[i] = math.Inf(1)
+		}
+		return dist
+	}
+
+	numObjectives := len(archive[0].objectives)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	for m := 0; m < numObjectives; m++ {
+		sort.Slice(order, func(a, b int) bool {
+			return archive[order[a]].objectives[m] < archive[order[b]].objectives[m]
+		})
+
+		dist[order[0]] = math.Inf(1)
+		dist[order[n-1]] = math.Inf(1)
+
+		span := archive[order[n-1]].objectives[m] - archive[order[0]].objectives[m]
+		if span == 0 {
+			continue
+		}
+
+		for i := 1; i < n-1; i++ {
+			prev := archive[order[i-1]].objectives[m]
+			next := archive[order[i+1]].objectives[m]
+			dist[order[i]] += (next - prev) / span
+		}
+	}
+
+	return dist
+}
+
+// sampleByCrowding picks an archive index, preferring sparse regions of the front (large crowding
+// distance) over crowded ones. Boundary points (infinite distance) are weighted as twice the
+// largest finite distance plus one so they remain favored without making every other member
+// unreachable.
+func sampleByCrowding(distances []float64) int {
+	if len(distances) == 0 {
+		return -1
+	}
+
+	maxFinite := 0.0
+	for _, d := range distances {
+		if !math.IsInf(d, 1) && d > maxFinite {
+			maxFinite = d
+		}
+	}
+
+	weights := make([]float64, len(distances))
+	total := 0.0
+	for i, d := range distances {
+		w := d
+		if math.IsInf(d, 1) {
+			w = 2*maxFinite + 1
+		}
+		weights[i] = w
+		total += w
+	}
+
+	if total <= 0 {
+		return rand.Intn(len(distances))
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r <= 0 {
+			return i
+		}
+	}
+	return len(distances) - 1
+}
+
+func (opt *MultiOptimizer) Step() {
+	if opt.options.BeforeStep != nil {
+		opt.options.BeforeStep()
+	}
+
+	opt.updateFitness()
+
+	if len(opt.archive) > 0 {
+		distances := crowdingDistances(opt.archive)
+
+		for idx := 0; idx < int(opt.options.PopulationSize); idx++ {
+			attractor := opt.archive[sampleByCrowding(distances)].position
+
+			ri := opt.positions[idx]
+			rp := scale(sub(opt.particleBestPosition[idx], ri), rand.Float64())
+			rg := scale(sub(attractor, ri), rand.Float64())
+			vi := opt.velocities[idx]
+
+			nv := sum(
+				scale(vi, opt.options.Inertia),
+				scale(rp, opt.options.ParticleStep),
+				scale(rg, opt.options.GlobalStep),
+			)
+			opt.velocities[idx] = nv
+
+			np := sum(opt.positions[idx], nv)
+			for i, bounds := range opt.options.Bounds {
+				opt.positions[idx][i] = bounds.Clip(np[i])
+			}
+		}
+	}
+
+	opt.stepCount++
+	if opt.options.Callback != nil {
+		opt.callbackErr = opt.options.Callback(opt.stepCount, opt.snapshot())
+	}
+}
+
+// StepUntil runs steps iterations of Step, stopping early if Options.Callback returns an error.
+func (opt *MultiOptimizer) StepUntil(steps int) {
+	opt.StepUntilCtx(context.Background(), steps)
+}
+
+// StepUntilCtx runs up to steps iterations of Step, stopping early if ctx is cancelled or
+// Options.Callback returns an error.
+func (opt *MultiOptimizer) StepUntilCtx(ctx context.Context, steps int) error {
+	opt.callbackErr = nil
+
+	for i := 0; i < steps; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		opt.Step()
+		if opt.options.Verbose {
+			log.Println(i, len(opt.archive))
+		}
+		if opt.callbackErr != nil {
+			return opt.callbackErr
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package swarm
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
@@ -17,9 +18,21 @@ var (
 // Lower values have a better score. To maximize a function, return the negative of the value.
 type Fitness func([]float64) float64
 
-// A Constraint sets hard limits on the range of values each parameter can take relative to other
-// parameters. Must return false if a particle position is invalid.
-type Constraint func([]float64) bool
+// A Constraint measures how far a particle position violates a soft limit relative to other
+// parameters. A return value <= 0 means the position is feasible; positive values measure the
+// magnitude of infeasibility and are used to rank infeasible particles against one another.
+type Constraint func([]float64) float64
+
+// BoolConstraint adapts a legacy boolean constraint (returning false for an invalid position)
+// into a Constraint, reporting a fixed violation of 1 for any position it rejects.
+func BoolConstraint(valid func([]float64) bool) Constraint {
+	return func(x []float64) float64 {
+		if valid(x) {
+			return 0
+		}
+		return 1
+	}
+}
 
 type Range [2]float64
 
@@ -65,13 +78,33 @@ type Options struct {
 	// Defaults to GOMAXPROCS.
 	Parallelism uint
 
+	// BatchFitness, if set, is used instead of Fitness to score a worker's whole chunk in one
+	// call: positions holds one entry per particle in the chunk, feasible or not, and the
+	// corresponding score must be written to the same index of out. Scores of particles outside
+	// Bounds or violating a Constraint are discarded. This lets fitness functions that can
+	// vectorize (e.g. a neural network forward pass over the whole swarm) avoid the overhead of
+	// a separate call per particle.
+	BatchFitness func(positions [][]float64, out []float64)
+
 	// Hard limits on the range of value each parameter can take.
 	// Defaults to unbounded for all dimensions.
 	// If a bound is the zero value for a Range, that dimension is unbounded.
 	Bounds []Range
 
-	// Hard limits on the range of values each parameter can take relative to other parameters.
-	// Defaults to unconstrained.
+	// Topology determines which other particles a given particle can see when forming its local
+	// attractor. Defaults to fixed-index groups of LocalSize particles, matching the original
+	// behavior of this package.
+	Topology Topology
+
+	// ArchiveSize caps the number of non-dominated solutions a MultiOptimizer retains. Once the
+	// archive grows past this size, the most crowded members (smallest crowding distance) are
+	// dropped first, NSGA-II style. Defaults to PopulationSize. Only used by MultiOptimizer.
+	ArchiveSize uint
+
+	// Soft limits on the range of values each parameter can take relative to other parameters.
+	// Defaults to unconstrained. Particles that violate a Constraint are not discarded; they are
+	// ranked behind every feasible particle, and against each other by total violation, so they
+	// still pull the swarm toward the feasible region.
 	Constraints []Constraint
 
 	// Hyperparameters which affect how quickly the particles converge on a local minima.
@@ -82,6 +115,30 @@ type Options struct {
 	GlobalStep   float64 // Defaults to 0.10
 	StallLimit   uint    // Defaults to 3
 
+	// NESBlend is the fraction (0-1) of each local group's particles that are, every Step,
+	// resampled from a per-group Normal(mu, sigma) distribution instead of following the usual
+	// PSO velocity update. 0 (the default) disables this natural-evolution-strategy hybrid step
+	// entirely. LRMu and LRSigma control how quickly mu and sigma adapt to the group's ranking.
+	// The "local group" NES adapts is always the fixed-index idx%groupCount partition, regardless
+	// of Topology: NES needs a disjoint partition of the whole swarm, which the other Topology
+	// implementations don't provide.
+	NESBlend float64
+	LRMu     float64 // Defaults to 0.1
+	LRSigma  float64 // Defaults to 0.1
+
+	// Callback, if set, is invoked after every Step with the step count and a Snapshot of the
+	// optimizer's current state. Returning a non-nil error stops the run cleanly at the next
+	// opportunity; StepUntilCtx surfaces it as its returned error, while StepUntil stops without
+	// returning it.
+	Callback Callback
+
+	// BeforeStep, if set, is invoked once at the very start of every Step, before Fitness or
+	// BatchFitness is evaluated for any particle. Fitness/BatchFitness is a plain function, so it
+	// has no way to know a new Step has begun; closures that hold shared per-step state (e.g.
+	// nnet.Network.MiniBatchFitness's mini-batch) should refresh that state here instead of on
+	// every call, so every particle in a Step is scored against the same sample.
+	BeforeStep func()
+
 	// Log progress
 	Verbose bool
 
@@ -99,16 +156,29 @@ type Optimizer struct {
 	velocities [][]float64 // [populationSize]velocity
 	stallCount []uint
 
-	particleBestPosition [][]float64 // [populationSize]position
-	particleBestFitness  []float64   // [populationSize]fitness
+	particleBestPosition  [][]float64 // [populationSize]position
+	particleBestFitness   []float64   // [populationSize]fitness
+	particleBestViolation []float64   // [populationSize]total constraint violation
 
-	localBestPosition [][]float64 // [groupCount]position
-	localBestFitness  []float64   // [groupCount]fitness
+	lastFitness   []float64 // [populationSize]fitness from the most recent Step
+	lastViolation []float64 // [populationSize]violation from the most recent Step
 
-	globalBestPosition []float64 // position
-	globalBestFitness  float64
+	globalBestPosition  []float64 // position
+	globalBestFitness   float64
+	globalBestViolation float64
 
 	averageFitness float64
+
+	nesState  []nesDistribution // [groupCount], lazily initialized once NESBlend > 0
+	resampled []bool            // [populationSize], particles NES resampled this Step
+	nesGroups *groupTopology    // fixed-index partition NES uses regardless of Options.Topology
+
+	stepCount   int
+	callbackErr error
+
+	workerJobs []chan [2]int // one job channel per persistent worker, each receiving [start,end)
+	workerWG   sync.WaitGroup
+	results    []particleFitness // [populationSize] scratch buffer workers write into directly
 }
 
 func (opt *Optimizer) Best() []float64 {
@@ -134,14 +204,45 @@ func New(fitness Fitness, shape []Range, options Options) (opt *Optimizer, err e
 		shape:   shape,
 	}
 
+	opt.options = withOptionDefaults(options, len(shape))
+
+	opt.Reset()
+	opt.startWorkerPool()
+	return
+}
+
+// Close stops the Optimizer's persistent worker pool, releasing its goroutines. Call it once an
+// Optimizer is no longer needed; an Optimizer is not usable after Close, since Reset does not
+// restart the pool.
+func (opt *Optimizer) Close() {
+	if opt == nil {
+		return
+	}
+
+	for _, jobs := range opt.workerJobs {
+		close(jobs)
+	}
+	opt.workerJobs = nil
+}
+
+// withOptionDefaults fills in zero-valued Options fields with the package defaults, given the
+// number of dimensions the optimizer will run over.
+func withOptionDefaults(options Options, dimensions int) Options {
 	if options.LocalSize == 0 {
 		options.LocalSize = 25
 	}
 	if options.PopulationSize == 0 {
-		options.PopulationSize = 10 * options.LocalSize * uint(len(shape))
+		options.PopulationSize = 10 * options.LocalSize * uint(dimensions)
+	}
+	if options.ArchiveSize == 0 {
+		options.ArchiveSize = options.PopulationSize
 	}
 	options.groupCount = int(options.PopulationSize / options.LocalSize)
 
+	if options.Topology == nil {
+		options.Topology = &groupTopology{groupCount: options.groupCount}
+	}
+
 	if options.Parallelism == 0 {
 		options.Parallelism = uint(runtime.GOMAXPROCS(0))
 	}
@@ -162,13 +263,36 @@ func New(fitness Fitness, shape []Range, options Options) (opt *Optimizer, err e
 		options.StallLimit = 3
 	}
 
+	if options.LRMu == 0 {
+		options.LRMu = 0.1
+	}
+	if options.LRSigma == 0 {
+		options.LRSigma = 0.1
+	}
+
 	if options.WaitMagnitude == 0.0 {
 		options.WaitMagnitude = 2
 	}
 
-	opt.options = options
+	return options
+}
 
-	opt.Reset()
+// initPositions creates randomized starting positions and velocities for populationSize
+// particles across shape.
+func initPositions(shape []Range, populationSize uint) (positions, velocities [][]float64) {
+	positions = make([][]float64, populationSize)
+	velocities = make([][]float64, populationSize)
+	for i := 0; i < int(populationSize); i++ {
+		positions[i] = make([]float64, len(shape))
+		velocities[i] = make([]float64, len(shape))
+	}
+	for j, r := range shape {
+		for i := 0; i < int(populationSize); i++ {
+			delta := r[1] - r[0]
+			positions[i][j] = r[0] + delta*rand.Float64()
+			velocities[i][j] = (2*rand.Float64() - 1) * delta
+		}
+	}
 	return
 }
 
@@ -177,149 +301,255 @@ func (opt *Optimizer) Reset() {
 		return
 	}
 
-	opt.positions = make([][]float64, opt.options.PopulationSize)
-	opt.velocities = make([][]float64, opt.options.PopulationSize)
+	opt.positions, opt.velocities = initPositions(opt.shape, opt.options.PopulationSize)
 	opt.stallCount = make([]uint, opt.options.PopulationSize)
 	opt.particleBestPosition = make([][]float64, opt.options.PopulationSize)
 	opt.particleBestFitness = make([]float64, opt.options.PopulationSize)
+	opt.particleBestViolation = make([]float64, opt.options.PopulationSize)
 	for i := 0; i < int(opt.options.PopulationSize); i++ {
-		opt.positions[i] = make([]float64, len(opt.shape))
-		opt.velocities[i] = make([]float64, len(opt.shape))
 		opt.particleBestPosition[i] = make([]float64, len(opt.shape))
 		opt.particleBestFitness[i] = math.MaxFloat64
-	}
-	for j, r := range opt.shape {
-		for i := 0; i < int(opt.options.PopulationSize); i++ {
-			delta := r[1] - r[0]
-			opt.positions[i][j] = r[0] + delta*rand.Float64()
-			opt.velocities[i][j] = (2*rand.Float64() - 1) * delta
-		}
+		opt.particleBestViolation[i] = math.MaxFloat64
 	}
 	copy(opt.particleBestPosition, opt.positions)
 
-	opt.localBestPosition = make([][]float64, opt.options.groupCount)
-	opt.localBestFitness = make([]float64, opt.options.groupCount)
-	for i := 0; i < opt.options.groupCount; i++ {
-		opt.localBestPosition[i] = make([]float64, len(opt.shape))
-		idx := i * int(opt.options.LocalSize)
-		copy(opt.localBestPosition[i], opt.positions[idx])
-		opt.localBestFitness[i] = math.MaxFloat64
+	opt.lastFitness = make([]float64, opt.options.PopulationSize)
+	opt.lastViolation = make([]float64, opt.options.PopulationSize)
+	for i := range opt.lastFitness {
+		opt.lastFitness[i] = math.MaxFloat64
+		opt.lastViolation[i] = math.MaxFloat64
 	}
 
 	opt.globalBestPosition = make([]float64, len(opt.shape))
 	copy(opt.globalBestPosition, opt.positions[0])
 	opt.globalBestFitness = math.MaxFloat64
-}
+	opt.globalBestViolation = math.MaxFloat64
 
-type particleFitness struct {
-	idx     int
-	fitness *float64
+	opt.nesState = nil
+	opt.resampled = nil
+	opt.nesGroups = nil
+
+	opt.stepCount = 0
+	opt.callbackErr = nil
+
+	opt.results = make([]particleFitness, opt.options.PopulationSize)
 }
 
-// calculate fitness of particle at idx
-func (opt *Optimizer) getParticleFitness(idx int) (result particleFitness) {
-	if opt == nil {
-		return
+// better reports whether (fitness, violation) ranks ahead of (otherFitness, otherViolation)
+// using feasibility-first comparison: any feasible position (violation <= 0) beats any
+// infeasible one, and among infeasible positions the smaller total violation wins.
+func better(fitness, violation, otherFitness, otherViolation float64) bool {
+	feasible := violation <= 0
+	otherFeasible := otherViolation <= 0
+	if feasible != otherFeasible {
+		return feasible
+	}
+	if feasible {
+		return fitness < otherFitness
 	}
+	return violation < otherViolation
+}
 
-	result.idx = idx
+type particleFitness struct {
+	idx       int
+	rejected  bool // position fell outside Bounds; no ranking information at all
+	fitness   *float64
+	violation float64 // sum of positive Constraint violations; <= 0 means feasible
+}
 
-	position := opt.positions[idx]
+// checkPosition reports the total positive Constraint violation for position (<= 0 means
+// feasible), and whether position falls outside Bounds entirely, in which case it carries no
+// ranking information at all and violation is meaningless.
+func (opt *Optimizer) checkPosition(position []float64) (violation float64, rejected bool) {
 	for i, bounds := range opt.options.Bounds {
 		if !bounds.Contains(position[i]) {
-			// position out of bounds
-			return
+			return 0, true
 		}
 	}
-	for _, withinConstraint := range opt.options.Constraints {
-		if !withinConstraint(position) {
-			// position exceeds constraint
-			return
+	for _, constraint := range opt.options.Constraints {
+		if v := constraint(position); v > 0 {
+			violation += v
 		}
 	}
+	return violation, false
+}
+
+// calculate fitness of particle at idx using Fitness, returning the result for the caller to store.
+func (opt *Optimizer) getParticleFitness(idx int) particleFitness {
+	result := particleFitness{idx: idx}
+
+	position := opt.positions[idx]
+	violation, rejected := opt.checkPosition(position)
+	if rejected {
+		result.rejected = true
+		return result
+	}
+	result.violation = violation
+	if violation > 0 {
+		// infeasible; still ranked against other infeasible particles by violation, but not
+		// worth spending a Fitness call on
+		return result
+	}
 
 	fitness := opt.fitness(position)
 	result.fitness = &fitness
-	return
+	return result
 }
 
-func (opt *Optimizer) updateFitness() {
-	if opt == nil {
-		return
+// evaluateBatch scores positions[start:end] in a single Options.BatchFitness call, writing
+// results into opt.results[start:end].
+func (opt *Optimizer) evaluateBatch(start, end int) {
+	positions := opt.positions[start:end]
+	fitnesses := make([]float64, len(positions))
+	opt.options.BatchFitness(positions, fitnesses)
+
+	for i, position := range positions {
+		idx := start + i
+		result := particleFitness{idx: idx}
+
+		violation, rejected := opt.checkPosition(position)
+		switch {
+		case rejected:
+			result.rejected = true
+		case violation > 0:
+			result.violation = violation
+		default:
+			fitness := fitnesses[i]
+			result.fitness = &fitness
+		}
+		opt.results[idx] = result
 	}
+}
 
-	// Launch workers
-	var wg sync.WaitGroup
-	todoIdx := make(chan int, int(opt.options.Parallelism))
-	results := make(chan particleFitness, int(opt.options.Parallelism))
-	for i := 0; i < int(opt.options.Parallelism); i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+// startWorkerPool launches Options.Parallelism persistent goroutines that evaluate contiguous
+// chunks of the population on demand, replacing the per-Step goroutine and channel churn of
+// feeding particles through one at a time.
+func (opt *Optimizer) startWorkerPool() {
+	workers := int(opt.options.Parallelism)
+	opt.workerJobs = make([]chan [2]int, workers)
+	for w := 0; w < workers; w++ {
+		jobs := make(chan [2]int)
+		opt.workerJobs[w] = jobs
 
-			for {
-				idx, ok := <-todoIdx
-				if !ok {
-					return
+		go func() {
+			for job := range jobs {
+				start, end := job[0], job[1]
+				if opt.options.BatchFitness != nil {
+					opt.evaluateBatch(start, end)
+				} else {
+					for idx := start; idx < end; idx++ {
+						opt.results[idx] = opt.getParticleFitness(idx)
+					}
 				}
-
-				results <- opt.getParticleFitness(idx)
+				opt.workerWG.Done()
 			}
 		}()
 	}
+}
+
+func (opt *Optimizer) updateFitness() {
+	if opt == nil {
+		return
+	}
 
-	// Feed workers
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	n := int(opt.options.PopulationSize)
+	workers := len(opt.workerJobs)
+	chunk := (n + workers - 1) / workers
 
-		for idx := 0; idx < int(opt.options.PopulationSize); idx++ {
-			todoIdx <- idx
+	opt.workerWG.Add(workers)
+	for w := 0; w < workers; w++ {
+		start := w * chunk
+		end := start + chunk
+		if start > n {
+			start = n
 		}
-	}()
+		if end > n {
+			end = n
+		}
+		if start >= end {
+			opt.workerWG.Done()
+			continue
+		}
+		opt.workerJobs[w] <- [2]int{start, end}
+	}
+	opt.workerWG.Wait()
 
 	// Retrieve completed work
 	var avg, count float64
-	for i := 0; i < int(opt.options.PopulationSize); i++ {
-		result := <-results
-		if result.fitness == nil {
+	for i := 0; i < n; i++ {
+		result := opt.results[i]
+		if result.rejected {
 			opt.stallCount[result.idx]++
 			continue
 		}
 
-		avg += *result.fitness
-		count++
+		fitness := math.MaxFloat64
+		if result.fitness != nil {
+			fitness = *result.fitness
+			avg += fitness
+			count++
+		}
+		opt.lastFitness[result.idx] = fitness
+		opt.lastViolation[result.idx] = result.violation
 
-		if *result.fitness < opt.particleBestFitness[result.idx] {
-			opt.particleBestFitness[result.idx] = *result.fitness
+		if better(fitness, result.violation, opt.particleBestFitness[result.idx], opt.particleBestViolation[result.idx]) {
+			opt.particleBestFitness[result.idx] = fitness
+			opt.particleBestViolation[result.idx] = result.violation
 			copy(opt.particleBestPosition[result.idx], opt.positions[result.idx])
 		}
 
-		groupIdx := result.idx % opt.options.groupCount
-		if *result.fitness < opt.localBestFitness[groupIdx] {
-			opt.localBestFitness[groupIdx] = *result.fitness
-			copy(opt.localBestPosition[groupIdx], opt.positions[result.idx])
+		if better(fitness, result.violation, opt.globalBestFitness, opt.globalBestViolation) {
+			opt.globalBestFitness = fitness
+			opt.globalBestViolation = result.violation
+			copy(opt.globalBestPosition, opt.positions[result.idx])
 		}
+	}
+	if count > 0 {
+		opt.averageFitness = avg / count
+	}
+}
 
-		if *result.fitness < opt.globalBestFitness {
-			opt.globalBestFitness = *result.fitness
-			copy(opt.globalBestPosition, opt.positions[result.idx])
+// localBest returns the best known position, fitness and violation among idx's neighbors in the
+// configured Topology, using each neighbor's own particle best (so it reflects what the
+// neighborhood has found, not just its current positions).
+func (opt *Optimizer) localBest(idx int) (position []float64, fitness, violation float64) {
+	fitness = math.MaxFloat64
+	violation = math.MaxFloat64
+	for _, j := range opt.options.Topology.Neighbors(idx, int(opt.options.PopulationSize)) {
+		if better(opt.particleBestFitness[j], opt.particleBestViolation[j], fitness, violation) {
+			fitness = opt.particleBestFitness[j]
+			violation = opt.particleBestViolation[j]
+			position = opt.particleBestPosition[j]
 		}
 	}
-	opt.averageFitness = avg / count
-	close(todoIdx)
-	wg.Wait()
+	return
 }
 
 func (opt *Optimizer) Step() {
+	if opt.options.BeforeStep != nil {
+		opt.options.BeforeStep()
+	}
+
 	opt.updateFitness()
 
+	if stepper, ok := opt.options.Topology.(Stepper); ok {
+		stepper.Step()
+	}
+
+	if opt.options.NESBlend > 0 {
+		opt.updateNES()
+	}
+
 	for idx := 0; idx < int(opt.options.PopulationSize); idx++ {
-		groupIdx := idx % opt.options.groupCount
+		if opt.resampled != nil && opt.resampled[idx] {
+			continue
+		}
+
+		localBestPosition, _, _ := opt.localBest(idx)
 
 		ri := opt.positions[idx]
 		rp := scale(sub(opt.particleBestPosition[idx], ri), rand.Float64())
-		rl := scale(sub(opt.localBestPosition[groupIdx], ri), rand.Float64())
+		rl := scale(sub(localBestPosition, ri), rand.Float64())
 		rg := scale(sub(opt.globalBestPosition, ri), rand.Float64())
 		vi := opt.velocities[idx]
 
@@ -336,6 +566,11 @@ func (opt *Optimizer) Step() {
 			opt.positions[idx][i] = bounds.Clip(np[i])
 		}
 	}
+
+	opt.stepCount++
+	if opt.options.Callback != nil {
+		opt.callbackErr = opt.options.Callback(opt.stepCount, opt.snapshot())
+	}
 }
 
 // element-wise vector summation, yields v0 + v1 + ... + vi
@@ -367,21 +602,47 @@ func scale(v []float64, s float64) []float64 {
 	return result
 }
 
+// StepUntil runs Step until progress on the global best fitness stalls, per the package's
+// log-ratio stopping heuristic. It also stops early if Options.Callback returns an error, but
+// does not return that error; use StepUntilCtx if you need to observe it or cancel the run.
 func (opt *Optimizer) StepUntil(progressRate float64) (steps int) {
+	steps, _ = opt.StepUntilCtx(context.Background(), progressRate)
+	return
+}
+
+// StepUntilCtx runs Step until progress stalls, ctx is cancelled, or Options.Callback returns an
+// error, whichever comes first. This makes long runs (the 14000-particle Golinski example can
+// take a while) cancellable, and lets callers plug in their own convergence detectors or
+// checkpointing via Callback without forking the loop.
+func (opt *Optimizer) StepUntilCtx(ctx context.Context, progressRate float64) (steps int, err error) {
 	if opt == nil {
 		return
 	}
 
+	opt.callbackErr = nil
+
 	opt.Step()
 	steps = 1
+	if opt.callbackErr != nil {
+		return steps, opt.callbackErr
+	}
 
 	minProgressRate := math.Abs(progressRate)
 	last := opt.globalBestFitness
 	stepsSinceImprovement := 0
 
 	for {
+		select {
+		case <-ctx.Done():
+			return steps, ctx.Err()
+		default:
+		}
+
 		opt.Step()
 		steps++
+		if opt.callbackErr != nil {
+			return steps, opt.callbackErr
+		}
 		if opt.options.Verbose {
 			log.Println(steps, opt.globalBestFitness, opt.averageFitness)
 		}
@@ -407,5 +668,5 @@ func (opt *Optimizer) StepUntil(progressRate float64) (steps int) {
 	if opt.options.Verbose {
 		log.Println(steps, opt.globalBestFitness)
 	}
-	return steps
+	return steps, nil
 }
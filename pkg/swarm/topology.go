@@ -0,0 +1,175 @@
+package swarm
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A Topology determines which other particles a given particle can see when forming its local
+// attractor. Neighbors is called with the population size on every Step, so a Topology is free to
+// compute its answer however it likes; implementations that want to change their neighbor sets
+// over time should also implement Stepper.
+type Topology interface {
+	// Neighbors returns the indices of the particles that idx can see, out of a population of
+	// size n. The returned set is conventionally expected to include idx itself.
+	Neighbors(idx, n int) []int
+}
+
+// A Stepper is notified once per Optimizer.Step, after fitness has been evaluated for that step.
+// Topologies that evolve over time (e.g. RandomTopology) implement this to know when to reshuffle.
+type Stepper interface {
+	Step()
+}
+
+// groupTopology reproduces this package's original neighborhoods: particle idx's neighbors are
+// every particle sharing idx % groupCount, i.e. arbitrary fixed-index slices of the population.
+// It is the default Topology so that leaving Options.Topology unset preserves prior behavior.
+//
+// The group->members partition is memoized the first time Neighbors sees a given population size
+// instead of being rescanned on every call: localBest calls Neighbors once per particle per Step,
+// so a full population scan per call would make neighbor resolution O(n^2).
+type groupTopology struct {
+	groupCount int
+
+	cachedN int
+	groups  [][]int // [group]memberIndices, built for cachedN
+}
+
+func (t *groupTopology) Neighbors(idx, n int) []int {
+	if t.groupCount <= 0 {
+		return []int{idx}
+	}
+
+	if t.groups == nil || t.cachedN != n {
+		t.buildGroups(n)
+	}
+	return t.groups[idx%t.groupCount]
+}
+
+func (t *groupTopology) buildGroups(n int) {
+	t.groups = make([][]int, t.groupCount)
+	for i := 0; i < n; i++ {
+		g := i % t.groupCount
+		t.groups[g] = append(t.groups[g], i)
+	}
+	t.cachedN = n
+}
+
+// RingTopology connects each particle to its K nearest neighbors by index, wrapping around the
+// population, forming a ring lattice with spatial coherence that fixed-index groups lack.
+type RingTopology struct {
+	// Number of neighbors each particle can see, split evenly on either side. Must be even.
+	// Defaults to 2 (one neighbor on each side).
+	K int
+}
+
+func (t RingTopology) Neighbors(idx, n int) []int {
+	k := t.K
+	if k <= 0 {
+		k = 2
+	}
+
+	half := k / 2
+	neighbors := make([]int, 0, k+1)
+	neighbors = append(neighbors, idx)
+	for d := 1; d <= half; d++ {
+		neighbors = append(neighbors, (idx+d)%n, (idx-d+n)%n)
+	}
+	return neighbors
+}
+
+// VonNeumannTopology arranges particles on a 2D grid of the given width and connects each
+// particle to its up/down/left/right neighbors, wrapping at the grid edges.
+type VonNeumannTopology struct {
+	// Number of columns in the grid. Defaults to ceil(sqrt(n)).
+	Width int
+}
+
+func (t VonNeumannTopology) Neighbors(idx, n int) []int {
+	width := t.Width
+	if width <= 0 {
+		width = int(math.Ceil(math.Sqrt(float64(n))))
+	}
+	if width <= 0 {
+		width = 1
+	}
+	height := (n + width - 1) / width
+
+	x, y := idx%width, idx/width
+	at := func(x, y int) int {
+		x = ((x % width) + width) % width
+		y = ((y % height) + height) % height
+		i := y*width + x
+		if i >= n {
+			i %= n
+		}
+		return i
+	}
+
+	return []int{idx, at(x+1, y), at(x-1, y), at(x, y+1), at(x, y-1)}
+}
+
+// FullyConnectedTopology connects every particle to every other particle, recovering the
+// classic gbest PSO where the local attractor is simply the global best.
+type FullyConnectedTopology struct{}
+
+func (FullyConnectedTopology) Neighbors(idx, n int) []int {
+	neighbors := make([]int, n)
+	for i := range neighbors {
+		neighbors[i] = i
+	}
+	return neighbors
+}
+
+// RandomTopology connects each particle to K randomly chosen neighbors, reshuffling every
+// ReshuffleEvery steps so the swarm doesn't settle into a fixed social structure.
+type RandomTopology struct {
+	// Number of random neighbors per particle, not counting itself. Defaults to 2.
+	K int
+	// Number of Step calls between reshuffles. Defaults to 1 (reshuffle every step).
+	ReshuffleEvery int
+
+	neighbors [][]int
+	steps     int
+}
+
+func (t *RandomTopology) Neighbors(idx, n int) []int {
+	if len(t.neighbors) != n {
+		t.reshuffle(n)
+	}
+	return append([]int{idx}, t.neighbors[idx]...)
+}
+
+func (t *RandomTopology) Step() {
+	t.steps++
+
+	every := t.ReshuffleEvery
+	if every <= 0 {
+		every = 1
+	}
+	if t.steps%every == 0 {
+		t.reshuffle(len(t.neighbors))
+	}
+}
+
+func (t *RandomTopology) reshuffle(n int) {
+	k := t.K
+	if k <= 0 {
+		k = 2
+	}
+
+	t.neighbors = make([][]int, n)
+	for i := 0; i < n; i++ {
+		picked := make([]int, 0, k)
+		for _, j := range rand.Perm(n) {
+			if j == i {
+				continue
+			}
+			picked = append(picked, j)
+			if len(picked) == k {
+				break
+			}
+		}
+		t.neighbors[i] = picked
+	}
+}